@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TypeOPT is the pseudo-RR type used to carry EDNS(0) metadata, RFC 6891.
+const TypeOPT uint16 = 41
+
+// EDNS option codes, RFC 6891 and friends.
+const (
+	OptCodeNSID         uint16 = 3
+	OptCodeClientSubnet uint16 = 8
+	OptCodeCookie       uint16 = 10
+)
+
+// defaultUDPPayloadSize is what this server advertises as its own receive
+// buffer size in the OPT records it emits.
+const defaultUDPPayloadSize = 4096
+
+// doBit is the DNSSEC OK bit, the high bit of the OPT TTL field.
+const doBit = 1 << 15
+
+// OPTOption is a single EDNS option: a TLV-encoded (code, length, data)
+// entry inside an OPT record's RDATA.
+type OPTOption struct {
+	Code uint16
+	Data []byte
+}
+
+// OPT is the RDATA of a TYPE=41 pseudo-RR. Unlike ordinary records, the
+// OPT record repurposes CLASS and TTL on its owning Answer to carry the
+// requester's UDP payload size, EDNS version, extended RCODE, and DO bit -
+// see edns info helpers below.
+type OPT struct {
+	Options []OPTOption
+}
+
+func (o *OPT) marshal(buf []byte, _ *NameCompressor) []byte {
+	for _, opt := range o.Options {
+		buf = append(buf, byte(opt.Code>>8), byte(opt.Code&0xFF))
+		buf = append(buf, byte(len(opt.Data)>>8), byte(len(opt.Data)&0xFF))
+		buf = append(buf, opt.Data...)
+	}
+	return buf
+}
+
+func (o *OPT) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	end := offset + int(rdlength)
+	pos := offset
+	for pos < end {
+		if pos+4 > end {
+			return fmt.Errorf("EDNS option at offset %d is missing code/length", pos)
+		}
+		code := binary.BigEndian.Uint16(msg[pos : pos+2])
+		length := binary.BigEndian.Uint16(msg[pos+2 : pos+4])
+		pos += 4
+		if pos+int(length) > end {
+			return fmt.Errorf("EDNS option at offset %d has data past RDATA end", pos)
+		}
+		o.Options = append(o.Options, OPTOption{
+			Code: code,
+			Data: append([]byte{}, msg[pos:pos+int(length)]...),
+		})
+		pos += int(length)
+	}
+	return nil
+}
+
+// EDNSInfo is the decoded form of an OPT pseudo-RR's Answer-level fields.
+type EDNSInfo struct {
+	UDPPayloadSize uint16
+	Version        uint8
+	ExtendedRCODE  uint8
+	DO             bool
+	Options        []OPTOption
+}
+
+// newOPTAnswer builds the OPT pseudo-RR Answer this server attaches to its
+// own responses to advertise info.UDPPayloadSize and friends.
+func newOPTAnswer(info EDNSInfo) Answer {
+	ttl := uint32(info.ExtendedRCODE)<<24 | uint32(info.Version)<<16
+	if info.DO {
+		ttl |= doBit
+	}
+	return Answer{
+		domainName:  "",
+		answerType:  TypeOPT,
+		answerClass: info.UDPPayloadSize,
+		TTL:         ttl,
+		rdata:       &OPT{Options: info.Options},
+	}
+}
+
+// findEDNSInfo scans additional for an OPT pseudo-RR and decodes it.
+func findEDNSInfo(additional []Answer) (EDNSInfo, bool) {
+	for _, answer := range additional {
+		if answer.answerType != TypeOPT {
+			continue
+		}
+		opt, ok := answer.rdata.(*OPT)
+		if !ok {
+			continue
+		}
+		return EDNSInfo{
+			UDPPayloadSize: answer.answerClass,
+			ExtendedRCODE:  uint8(answer.TTL >> 24),
+			Version:        uint8(answer.TTL >> 16),
+			DO:             answer.TTL&doBit != 0,
+			Options:        opt.Options,
+		}, true
+	}
+	return EDNSInfo{}, false
+}
+
+// splitExtendedRCODE splits a 12-bit extended RCODE (RFC 6891 §6.1.3) into
+// the 4-bit value that goes in Header.RCODE and the high byte that goes in
+// the OPT record's TTL field.
+func splitExtendedRCODE(rcode uint16) (headerRCODE uint8, optRCODEHigh uint8) {
+	return uint8(rcode & 0xF), uint8(rcode >> 4)
+}
+
+// joinExtendedRCODE recombines a Header.RCODE and an OPT record's extended
+// RCODE high byte into the full 12-bit RCODE.
+func joinExtendedRCODE(headerRCODE uint8, optRCODEHigh uint8) uint16 {
+	return uint16(optRCODEHigh)<<4 | uint16(headerRCODE&0xF)
+}
@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestEDNSRoundTrip(t *testing.T) {
+	info := EDNSInfo{
+		UDPPayloadSize: 4096,
+		Version:        0,
+		ExtendedRCODE:  0,
+		DO:             true,
+		Options: []OPTOption{
+			{Code: OptCodeCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		},
+	}
+
+	answer := newOPTAnswer(info)
+	buf := answer.marshal()
+
+	decoded, _, err := unmarshalAnswer(buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := findEDNSInfo([]Answer{decoded})
+	if !ok {
+		t.Fatal("expected findEDNSInfo to recognise the OPT record")
+	}
+
+	if got.UDPPayloadSize != info.UDPPayloadSize {
+		t.Errorf("expected UDPPayloadSize %d, got %d", info.UDPPayloadSize, got.UDPPayloadSize)
+	}
+	if got.DO != info.DO {
+		t.Errorf("expected DO %v, got %v", info.DO, got.DO)
+	}
+	if len(got.Options) != 1 || got.Options[0].Code != OptCodeCookie {
+		t.Errorf("expected one COOKIE option, got %+v", got.Options)
+	}
+}
+
+func TestSplitJoinExtendedRCODE(t *testing.T) {
+	headerRCODE, optHigh := splitExtendedRCODE(0x123)
+	if headerRCODE != 0x3 || optHigh != 0x12 {
+		t.Errorf("expected (0x3, 0x12), got (%x, %x)", headerRCODE, optHigh)
+	}
+
+	if joinExtendedRCODE(headerRCODE, optHigh) != 0x123 {
+		t.Errorf("expected round trip to 0x123, got %x", joinExtendedRCODE(headerRCODE, optHigh))
+	}
+}
+
+func TestBuildWithTruncationSetsTC(t *testing.T) {
+	aRecord, err := NewARecord("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answers := make([]Answer, 50)
+	for i := range answers {
+		answers[i] = Answer{
+			domainName:  "codecrafters.io",
+			answerType:  TypeA,
+			answerClass: 1,
+			TTL:         60,
+			rdata:       aRecord,
+		}
+	}
+
+	response := DNSResponse{
+		header:   Header{ID: 1},
+		question: []Question{{domainName: "codecrafters.io", questionType: TypeA, questionClass: 1}},
+		answer:   answers,
+	}
+
+	buf := buildWithTruncation(response, 100)
+
+	decodedHeader, err := unmarshalHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decodedHeader.TC != 1 {
+		t.Error("expected TC bit to be set when answers had to be dropped")
+	}
+	if len(buf) > 100 {
+		t.Errorf("expected truncated response to fit in 100 bytes, got %d", len(buf))
+	}
+}
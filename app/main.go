@@ -2,11 +2,10 @@ package main
 
 import (
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"log"
 	"net"
-	"strconv"
-	"strings"
 )
 
 type Header struct {
@@ -97,7 +96,7 @@ func unmarshalHeader(data []byte) (Header, error) {
 
 	header.RA = (data[3] >> 7)
 	header.Z = (data[3] >> 4) & 0x7
-	header.Z = data[3] & 0xF
+	header.RCODE = data[3] & 0xF
 
 	header.QDCOUNT = binary.BigEndian.Uint16(data[4:6])
 
@@ -180,49 +179,51 @@ type Question struct {
 	questionClass uint16
 }
 
-func unmarshalQuestion(data []byte) (Question, int, error) {
-	index := 0
-	question := Question{}
-
-	for len(data) > index && data[index] != 0 {
-		if index != 0 {
-			question.domainName += "."
-		}
-		length := int(data[index])
-
-		label := data[index+1 : index+length+1]
+// unmarshalQuestion decodes a Question starting at offset within the full
+// message msg, following compression pointers per RFC 1035 §4.1.4. The
+// returned int is the number of bytes consumed from the caller's stream,
+// i.e. starting at offset (see decodeName for the pointer-at-end case).
+func unmarshalQuestion(msg []byte, offset int) (Question, int, error) {
+	domainName, nameBytes, err := decodeName(msg, offset)
+	if err != nil {
+		return Question{}, 0, err
+	}
 
-		question.domainName += string(label)
+	pos := offset + nameBytes
+	if pos+4 > len(msg) {
+		return Question{}, 0, fmt.Errorf("question at offset %d is missing type/class", offset)
+	}
 
-		index += length + 1
+	question := Question{
+		domainName:    domainName,
+		questionType:  binary.BigEndian.Uint16(msg[pos : pos+2]),
+		questionClass: binary.BigEndian.Uint16(msg[pos+2 : pos+4]),
 	}
-	index++
-	question.questionType = binary.BigEndian.Uint16(data[index : index+2])
-	question.questionClass = binary.BigEndian.Uint16(data[index+2 : index+4])
 
-	return question, index + 4, nil
+	return question, nameBytes + 4, nil
 }
 
+// marshal encodes the question with no compression, suitable for standalone
+// use. DNSResponse.build uses marshalWithCompression instead so that names
+// shared with earlier records in the message can be pointer-compressed.
 func (question *Question) marshal() []byte {
-	var response []byte
+	return question.marshalWithCompression(nil, nil)
+}
 
-	for _, label := range strings.Split(question.domainName, ".") {
-		if len(label) == 0 {
-			continue
-		}
-		response = append(response, byte(len(label)))
-		response = append(response, label...)
+func (question *Question) marshalWithCompression(buf []byte, compressor *NameCompressor) []byte {
+	if compressor != nil {
+		buf = compressor.encodeName(buf, question.domainName)
+	} else {
+		buf = encodeNamePlain(buf, question.domainName)
 	}
 
-	response = append(response, 0)
+	buf = append(buf, byte(question.questionType>>8))
+	buf = append(buf, byte(question.questionType&0xFF))
 
-	response = append(response, byte(question.questionType>>8))
-	response = append(response, byte(question.questionType&0xFF))
+	buf = append(buf, byte(question.questionClass>>8))
+	buf = append(buf, byte(question.questionClass&0xFF))
 
-	response = append(response, byte(question.questionClass>>8))
-	response = append(response, byte(question.questionClass&0xFF))
-
-	return response
+	return buf
 }
 
 type Answer struct {
@@ -272,108 +273,287 @@ type Answer struct {
 	RDLENGTH uint16
 
 	// Variable
-	// Data specific to the record type.
-	data string
+	// Data specific to the record type, dispatched on answerType. See rr.go.
+	rdata RR
 }
 
+// marshal encodes the answer with no compression, suitable for standalone
+// use. DNSResponse.build uses marshalWithCompression instead so that names
+// shared with earlier records in the message can be pointer-compressed.
 func (answer *Answer) marshal() []byte {
-	var response []byte
+	return answer.marshalWithCompression(nil, nil)
+}
 
-	for _, label := range strings.Split(answer.domainName, ".") {
-		if len(label) == 0 {
-			continue
-		}
-		response = append(response, byte(len(label)))
-		response = append(response, label...)
+func (answer *Answer) marshalWithCompression(buf []byte, compressor *NameCompressor) []byte {
+	if compressor != nil {
+		buf = compressor.encodeName(buf, answer.domainName)
+	} else {
+		buf = encodeNamePlain(buf, answer.domainName)
 	}
 
-	response = append(response, 0)
-
-	response = append(response, byte(answer.answerType>>8))
-	response = append(response, byte(answer.answerType&0xFF))
+	buf = append(buf, byte(answer.answerType>>8))
+	buf = append(buf, byte(answer.answerType&0xFF))
 
-	response = append(response, byte(answer.answerClass>>8))
-	response = append(response, byte(answer.answerClass&0xFF))
+	buf = append(buf, byte(answer.answerClass>>8))
+	buf = append(buf, byte(answer.answerClass&0xFF))
 
-	response = append(response, byte(answer.TTL>>24))
-	response = append(response, byte((answer.TTL>>16)&0xFF))
-	response = append(response, byte((answer.TTL>>8)&0xFF))
-	response = append(response, byte(answer.TTL&0xFF))
+	buf = append(buf, byte(answer.TTL>>24))
+	buf = append(buf, byte((answer.TTL>>16)&0xFF))
+	buf = append(buf, byte((answer.TTL>>8)&0xFF))
+	buf = append(buf, byte(answer.TTL&0xFF))
 
-	response = append(response, byte(answer.RDLENGTH>>8))
-	response = append(response, byte(answer.RDLENGTH&0xFF))
+	// RDLENGTH is unknown until the RDATA is written, so reserve it and
+	// patch it in once we know how many bytes rdata.marshal produced.
+	rdlengthPos := len(buf)
+	buf = append(buf, 0, 0)
+	rdataStart := len(buf)
 
-	for _, label := range strings.Split(answer.data, ".") {
-		if len(label) == 0 {
-			continue
-		}
-		n, err := strconv.Atoi(label)
-		if err != nil {
-			panic("cant convert")
-		}
-		response = append(response, byte(n))
+	if answer.rdata != nil {
+		buf = answer.rdata.marshal(buf, compressor)
 	}
 
-	return response
-}
-
-func unmarshalAnswer(data []byte) (Answer, int, error) {
-	index := 0
-	answer := Answer{}
-
-	for len(data) > index && data[index] != 0 {
-		if index != 0 {
-			answer.domainName += "."
-		}
-		length := int(data[index])
+	rdlength := len(buf) - rdataStart
+	buf[rdlengthPos] = byte(rdlength >> 8)
+	buf[rdlengthPos+1] = byte(rdlength & 0xFF)
 
-		label := data[index+1 : index+length+1]
+	return buf
+}
 
-		answer.domainName += string(label)
+// unmarshalAnswer decodes an Answer starting at offset within the full
+// message msg, following compression pointers per RFC 1035 §4.1.4 when
+// decoding the name, and dispatching RDATA decoding to the RR implementation
+// registered for answerType.
+func unmarshalAnswer(msg []byte, offset int) (Answer, int, error) {
+	domainName, nameBytes, err := decodeName(msg, offset)
+	if err != nil {
+		return Answer{}, 0, err
+	}
 
-		index += length + 1
+	pos := offset + nameBytes
+	if pos+10 > len(msg) {
+		return Answer{}, 0, fmt.Errorf("answer at offset %d is missing type/class/ttl/rdlength", offset)
 	}
-	index++
-	answer.answerType = binary.BigEndian.Uint16(data[index : index+2])
-	answer.answerClass = binary.BigEndian.Uint16(data[index+2 : index+4])
 
-	answer.TTL = binary.BigEndian.Uint32(data[index+4 : index+8])
-	answer.RDLENGTH = binary.BigEndian.Uint16(data[index+8 : index+10])
+	rdlength := binary.BigEndian.Uint16(msg[pos+8 : pos+10])
+	answer := Answer{
+		domainName:  domainName,
+		answerType:  binary.BigEndian.Uint16(msg[pos : pos+2]),
+		answerClass: binary.BigEndian.Uint16(msg[pos+2 : pos+4]),
+		TTL:         binary.BigEndian.Uint32(msg[pos+4 : pos+8]),
+		RDLENGTH:    rdlength,
+	}
 
-	for i := index + 10; i < index+10+int(answer.RDLENGTH); i++ {
-		answer.data += strconv.Itoa(int(data[i]))
-		if i < index+10+int(answer.RDLENGTH)-1 {
+	rdataStart := pos + 10
+	if rdataStart+int(rdlength) > len(msg) {
+		return Answer{}, 0, fmt.Errorf("answer at offset %d has RDATA past end of message", offset)
+	}
 
-			answer.data += "."
-		}
+	rr := newRR(answer.answerType)
+	if err := rr.unmarshal(msg, rdataStart, rdlength); err != nil {
+		return Answer{}, 0, fmt.Errorf("answer at offset %d: %w", offset, err)
 	}
+	answer.rdata = rr
 
-	return answer, index + 10 + int(answer.RDLENGTH), nil
+	return answer, nameBytes + 10 + int(rdlength), nil
 }
 
 type DNSResponse struct {
-	header   Header
-	question []Question
-	answer   []Answer
+	header     Header
+	question   []Question
+	answer     []Answer
+	authority  []Answer
+	additional []Answer
 }
 
 func (dnsResponse *DNSResponse) build() []byte {
 	dnsResponse.header.QDCOUNT = uint16(len(dnsResponse.question))
 	dnsResponse.header.ANCOUNT = uint16(len(dnsResponse.answer))
+	dnsResponse.header.NSCOUNT = uint16(len(dnsResponse.authority))
+	dnsResponse.header.ARCOUNT = uint16(len(dnsResponse.additional))
 	resp := dnsResponse.header.marshal()
 
+	compressor := NewNameCompressor()
+
 	for _, question := range dnsResponse.question {
-		resp = append(resp, question.marshal()...)
+		resp = question.marshalWithCompression(resp, compressor)
 	}
 
 	for _, answer := range dnsResponse.answer {
-		resp = append(resp, answer.marshal()...)
+		resp = answer.marshalWithCompression(resp, compressor)
+	}
+
+	for _, authority := range dnsResponse.authority {
+		resp = authority.marshalWithCompression(resp, compressor)
+	}
+
+	for _, additional := range dnsResponse.additional {
+		resp = additional.marshalWithCompression(resp, compressor)
 	}
 
 	return resp
 }
 
+// newConfiguredResolver builds the Resolver for Forward/Recursive mode from
+// the --resolver flag, the --recursive flag, or, failing those, /etc/resolv.conf.
+// It returns a nil Resolver (and Authoritative mode) if none is available,
+// leaving the server to fall back to its built-in answers.
+func newConfiguredResolver(resolverFlag string, recursiveFlag bool) (Resolver, ServerConfig) {
+	config := ServerConfig{Mode: ModeAuthoritative, Timeout: defaultResolverTimeout}
+
+	if resolverFlag != "" {
+		config.Mode = ModeForward
+		config.Upstreams = []string{resolverFlag}
+		return NewForwarder(config.Upstreams), config
+	}
+
+	if recursiveFlag {
+		config.Mode = ModeRecursive
+		return NewRecursive(), config
+	}
+
+	if upstreams, err := loadUpstreamsFromResolvConf(defaultResolvConfPath); err == nil {
+		config.Mode = ModeForward
+		config.Upstreams = upstreams
+		return NewForwarder(upstreams), config
+	}
+
+	return nil, config
+}
+
+const defaultResolvConfPath = "/etc/resolv.conf"
+
+// maxTCPResponseSize is the largest RDATA TCP framing (a 2-byte length
+// prefix) can describe, RFC 1035 §4.2.2.
+const maxTCPResponseSize = 0xFFFF
+
+// handleQuery is the transport-agnostic request handler shared by the UDP
+// and TCP listeners in main: it decodes queryData, resolves or answers it,
+// and returns the wire-format response, or nil if queryData couldn't be
+// handled. isTCP relaxes the UDP 512-byte response cap, since TCP framing
+// has no such limit.
+func handleQuery(queryData []byte, resolver Resolver, zone *Zone, isTCP bool) []byte {
+	// Forwarder relays an upstream-sized reply verbatim, already subject to
+	// whatever size limit the upstream applied, so it bypasses the
+	// truncation machinery below entirely. Recursive builds its own
+	// response from scratch and so still needs to go through it.
+	recursive, isRecursive := resolver.(*Recursive)
+	if resolver != nil && !isRecursive {
+		resp, err := resolver.Resolve(queryData)
+		if err != nil {
+			fmt.Println("Failed to resolve query:", err)
+			return nil
+		}
+		return resp
+	}
+
+	recivedMessage, err := unmarshalMessage(queryData)
+	if err != nil {
+		fmt.Println("couldn't parse message:", err)
+		return nil
+	}
+	if len(recivedMessage.question) == 0 {
+		fmt.Println("received message with no questions, dropping")
+		return nil
+	}
+	recivedQuestion := recivedMessage.question[0]
+	fmt.Printf("%+v\n", recivedQuestion)
+
+	header := Header{
+		ID:     recivedMessage.header.ID,
+		QR:     1,
+		RD:     recivedMessage.header.RD,
+		OPCODE: recivedMessage.header.OPCODE,
+	}
+	if recivedMessage.header.OPCODE == 0 {
+		header.RCODE = 0
+	} else {
+		header.RCODE = 4
+	}
+	question := Question{
+		domainName:    recivedQuestion.domainName,
+		questionClass: recivedQuestion.questionClass,
+		questionType:  recivedQuestion.questionType,
+	}
+	response := DNSResponse{
+		header:   header,
+		question: []Question{question},
+	}
+
+	if isRecursive && header.OPCODE == 0 {
+		answers, rcode := recursive.resolveAnswers(question)
+		header.RCODE = rcode
+		response.answer = answers
+	} else if zone != nil && header.OPCODE == 0 {
+		header.AA = 1
+		answers, authority, additional, rcode := zone.Resolve(recivedQuestion)
+		header.RCODE = rcode
+		response.answer = answers
+		response.authority = authority
+		response.additional = additional
+	} else {
+		aRecord, err := NewARecord("8.8.8.8")
+		if err != nil {
+			fmt.Println("couldn't build A record:", err)
+			return nil
+		}
+		response.answer = []Answer{{
+			domainName:  recivedQuestion.domainName,
+			answerType:  TypeA,
+			answerClass: 1,
+			TTL:         60,
+			rdata:       aRecord,
+		}}
+	}
+
+	// UDP responses are capped at 512 bytes unless the requester advertised
+	// a larger buffer via an EDNS(0) OPT record; TCP framing has no such
+	// cap at all.
+	maxResponseSize := 512
+	if isTCP {
+		maxResponseSize = maxTCPResponseSize
+	}
+	if edns, ok := findEDNSInfo(recivedMessage.additional); ok {
+		negotiated := int(edns.UDPPayloadSize)
+		if negotiated > defaultUDPPayloadSize {
+			negotiated = defaultUDPPayloadSize
+		}
+		if !isTCP && negotiated > maxResponseSize {
+			maxResponseSize = negotiated
+		}
+		// The RCODE may need more than 4 bits (RFC 6891 §6.1.3); split it
+		// between the header's low nibble and the OPT record's high byte.
+		headerRCODE, extendedRCODE := splitExtendedRCODE(uint16(response.header.RCODE))
+		response.header.RCODE = headerRCODE
+		response.additional = append(response.additional, newOPTAnswer(EDNSInfo{
+			UDPPayloadSize: defaultUDPPayloadSize,
+			Version:        edns.Version,
+			ExtendedRCODE:  extendedRCODE,
+		}))
+	}
+
+	return buildWithTruncation(response, maxResponseSize)
+}
+
 func main() {
+	resolverFlag := flag.String("resolver", "", "forward queries to this upstream host:port instead of answering locally")
+	recursiveFlag := flag.Bool("recursive", false, "resolve queries recursively from the root hints instead of forwarding or answering locally")
+	zoneFlag := flag.String("zone", "", "serve this master file authoritatively instead of echoing 8.8.8.8")
+	mdnsFlag := flag.Bool("mdns", false, "also answer mDNS queries on 224.0.0.251:5353 using --zone's .local. records")
+	flag.Parse()
+
+	resolver, config := newConfiguredResolver(*resolverFlag, *recursiveFlag)
+	fmt.Printf("running in mode: %v\n", config.Mode)
+
+	var zone *Zone
+	if *zoneFlag != "" {
+		loaded, err := LoadZone(*zoneFlag)
+		if err != nil {
+			log.Fatal(fmt.Printf("couldn't load zone file, err: %v", err))
+		}
+		zone = loaded
+		fmt.Printf("serving zone %q from %s\n", zone.Origin, *zoneFlag)
+	}
+
 	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:2053")
 
 	if err != nil {
@@ -388,7 +568,24 @@ func main() {
 		log.Fatal(fmt.Printf("couldn't create connection, err: %v", err))
 	}
 
-	buffer := make([]byte, 2048)
+	tcpAddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:2053")
+	if err != nil {
+		log.Fatal(fmt.Printf("couldn't resolve TCP addres, err: %v", err))
+	}
+
+	tcpListener, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		log.Fatal(fmt.Printf("couldn't create TCP listener, err: %v", err))
+	}
+	defer tcpListener.Close()
+
+	go serveTCP(tcpListener, resolver, zone)
+
+	if *mdnsFlag {
+		go serveMDNS(zone)
+	}
+
+	buffer := make([]byte, defaultUDPPayloadSize)
 
 	for {
 		size, source, err := conn.ReadFromUDP(buffer)
@@ -402,49 +599,12 @@ func main() {
 
 		fmt.Printf("recived data: %s, source: %s \n", recivedData, source)
 		fmt.Printf("raw data: %v \n", recivedData)
-		recivedHeader, err := unmarshalHeader(recivedData)
-		recivedQuestion, readBytes, err := unmarshalQuestion(recivedData[12:])
-		recivedAnswer, readBytes, err := unmarshalAnswer(recivedData[12+readBytes:])
-		fmt.Printf("%+v\n", recivedQuestion)
-		fmt.Printf("%+v\n", recivedAnswer)
-		if err != nil {
-			log.Fatal(fmt.Printf("couldn't parse header err: %v", err))
-			break
-		}
 
-		header := Header{
-			ID:     recivedHeader.ID,
-			QR:     1,
-			RD:     recivedHeader.RD,
-			OPCODE: recivedHeader.OPCODE,
-		}
-		if recivedHeader.OPCODE == 0 {
-			header.RCODE = 0
-		} else {
-			header.RCODE = 4
-		}
-		question := Question{
-			domainName:    recivedQuestion.domainName,
-			questionClass: 1,
-			questionType:  1,
-		}
-		answer := Answer{
-			domainName:  recivedQuestion.domainName,
-			answerType:  1,
-			answerClass: 1,
-			TTL:         60,
-			RDLENGTH:    4,
-			data:        "8.8.8.8",
-		}
-		response := DNSResponse{
-			header:   header,
-			question: []Question{question},
-			answer:   []Answer{answer},
+		resp := handleQuery(recivedData, resolver, zone, false)
+		if resp == nil {
+			continue
 		}
-
-		_, err = conn.WriteToUDP(response.build(), source)
-
-		if err != nil {
+		if _, err := conn.WriteToUDP(resp, source); err != nil {
 			fmt.Println("Failed to send response:", err)
 		}
 	}
@@ -35,13 +35,17 @@ func TestQuestion(t *testing.T) {
 }
 
 func TestAnswer(t *testing.T) {
+	aRecord, err := NewARecord("8.8.8.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	answer := Answer{
 		domainName:  "codecrafters.io",
-		answerType:  1,
+		answerType:  TypeA,
 		answerClass: 1,
 		TTL:         60,
-		RDLENGTH:    4,
-		data:        "8.8.8.8",
+		rdata:       aRecord,
 	}
 
 	bytes := answer.marshal()
@@ -56,7 +60,7 @@ func TestAnswer(t *testing.T) {
 func TestUnmarshalQuestion(t *testing.T) {
 	data := []byte("\x0ccodecrafters\x02io\x00\x00\x01\x00\x01")
 
-	question, bytesRead, err := unmarshalQuestion(data)
+	question, bytesRead, err := unmarshalQuestion(data, 0)
 
 	if err != nil {
 		t.Error(err)
@@ -82,7 +86,7 @@ func TestUnmarshalQuestion(t *testing.T) {
 func TestUnmarshalAnswer(t *testing.T) {
 	data := []byte("\x0ccodecrafters\x02io\x00\x00\x01\x00\x01\x00\x00\x00\x3C\x00\x04\x08\x08\x08\x08")
 
-	answer, bytesRead, err := unmarshalAnswer(data)
+	answer, bytesRead, err := unmarshalAnswer(data, 0)
 
 	if err != nil {
 		t.Error(err)
@@ -112,8 +116,12 @@ func TestUnmarshalAnswer(t *testing.T) {
 		t.Errorf("Expected rdlength to be 4 got : %v", answer.RDLENGTH)
 	}
 
-	if answer.data != "8.8.8.8" {
-		t.Errorf("Expected data to be 8.8.8.8: %v", answer.data)
+	aRecord, ok := answer.rdata.(*ARecord)
+	if !ok {
+		t.Fatalf("Expected rdata to be an *ARecord, got: %T", answer.rdata)
+	}
+	if aRecord.IP.String() != "8.8.8.8" {
+		t.Errorf("Expected IP to be 8.8.8.8: %v", aRecord.IP)
 	}
 
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mdnsIPv4Group = "224.0.0.251"
+	mdnsIPv6Group = "ff02::fb"
+	mdnsPort      = 5353
+
+	// mdnsQUBit is the "QU" (unicast-response-desired) bit RFC 6762 §5.4
+	// steals from the high bit of a question's QCLASS.
+	mdnsQUBit uint16 = 1 << 15
+
+	// mdnsCacheFlushBit is the cache-flush bit RFC 6762 §10.2 steals from
+	// the high bit of an answer's CLASS.
+	mdnsCacheFlushBit uint16 = 1 << 15
+
+	// mdnsAggregationWindow is how long a responder waits after a
+	// truncated (TC=1) query for the remaining known-answer packets
+	// before answering, RFC 6762 §7.2.
+	mdnsAggregationWindow = 1 * time.Second
+)
+
+// class returns the question's real QCLASS with the QU bit masked out, so
+// callers that don't care about mDNS semantics (e.g. zone lookups) don't
+// need to know about it.
+func (q Question) class() uint16 {
+	return q.questionClass &^ mdnsQUBit
+}
+
+// unicastRequested reports whether the querier set the QU bit, asking for
+// a unicast rather than multicast reply, RFC 6762 §5.4.
+func (q Question) unicastRequested() bool {
+	return q.questionClass&mdnsQUBit != 0
+}
+
+// class returns the answer's real CLASS with the cache-flush bit masked
+// out.
+func (a Answer) class() uint16 {
+	return a.answerClass &^ mdnsCacheFlushBit
+}
+
+// cacheFlush reports whether the responder is asserting this is the
+// complete set of records for the name, RFC 6762 §10.2, superseding
+// anything previously cached for it.
+func (a Answer) cacheFlush() bool {
+	return a.answerClass&mdnsCacheFlushBit != 0
+}
+
+// isGoodbye reports whether the answer is a "goodbye" announcement, RFC
+// 6762 §10.1: a TTL of 0 asks listeners to flush the record immediately.
+func (a Answer) isGoodbye() bool {
+	return a.TTL == 0
+}
+
+// mdnsPendingQuery accumulates known-answer suppression records for a
+// question across the 1-second aggregation window, RFC 6762 §7.2.
+type mdnsPendingQuery struct {
+	question     Question
+	knownAnswers []Answer
+	unicastAddr  *net.UDPAddr
+	conn         *net.UDPConn
+	timer        *time.Timer
+}
+
+// MDNSServer answers mDNS queries using the records in zone, which are
+// expected to be names ending in ".local.".
+type MDNSServer struct {
+	zone *Zone
+
+	mu      sync.Mutex
+	pending map[string]*mdnsPendingQuery
+}
+
+func NewMDNSServer(zone *Zone) *MDNSServer {
+	return &MDNSServer{zone: zone, pending: make(map[string]*mdnsPendingQuery)}
+}
+
+func mdnsQuestionKey(q Question) string {
+	return fmt.Sprintf("%s|%d|%d", strings.ToLower(q.domainName), q.questionType, q.class())
+}
+
+// serveMDNS joins the IPv4 mDNS multicast group (and, best-effort, the
+// IPv6 one) and serves queries using zone's records until the IPv4
+// listener fails.
+func serveMDNS(zone *Zone) {
+	server := NewMDNSServer(zone)
+
+	conn4, err := net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP(mdnsIPv4Group), Port: mdnsPort})
+	if err != nil {
+		fmt.Println("couldn't join mDNS IPv4 group:", err)
+		return
+	}
+	defer conn4.Close()
+
+	conn6, err := net.ListenMulticastUDP("udp6", nil, &net.UDPAddr{IP: net.ParseIP(mdnsIPv6Group), Port: mdnsPort})
+	if err != nil {
+		fmt.Println("couldn't join mDNS IPv6 group, continuing on IPv4 only:", err)
+	} else {
+		defer conn6.Close()
+		go server.serve(conn6)
+	}
+
+	server.serve(conn4)
+}
+
+func (s *MDNSServer) serve(conn *net.UDPConn) {
+	buffer := make([]byte, defaultUDPPayloadSize)
+	for {
+		size, source, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			fmt.Println("couldn't read mDNS packet:", err)
+			return
+		}
+		s.handlePacket(append([]byte{}, buffer[:size]...), source, conn)
+	}
+}
+
+func (s *MDNSServer) handlePacket(data []byte, source *net.UDPAddr, conn *net.UDPConn) {
+	message, err := unmarshalMessage(data)
+	if err != nil {
+		fmt.Println("couldn't parse mDNS message:", err)
+		return
+	}
+	if message.header.QR == 1 {
+		// A response from another responder on the network, not a query.
+		return
+	}
+
+	truncated := message.header.TC == 1
+	for _, question := range message.question {
+		s.queueQuestion(question, message.answer, truncated, source, conn)
+	}
+}
+
+// queueQuestion records question's known-answer records and either
+// answers immediately (the common case, where the whole query fit in one
+// packet) or, if the query was truncated, waits out mdnsAggregationWindow
+// for the remaining known-answer packets first, per RFC 6762 §7.2.
+func (s *MDNSServer) queueQuestion(question Question, knownAnswers []Answer, truncated bool, source *net.UDPAddr, conn *net.UDPConn) {
+	key := mdnsQuestionKey(question)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[key]
+	if !ok {
+		pending = &mdnsPendingQuery{question: question, conn: conn}
+		s.pending[key] = pending
+	}
+	pending.knownAnswers = append(pending.knownAnswers, knownAnswers...)
+	if question.unicastRequested() {
+		pending.unicastAddr = source
+	}
+	if pending.timer != nil {
+		pending.timer.Stop()
+	}
+
+	if truncated {
+		pending.timer = time.AfterFunc(mdnsAggregationWindow, func() { s.flush(key) })
+		return
+	}
+
+	pending.timer = nil
+	s.flushLocked(key)
+}
+
+func (s *MDNSServer) flush(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked(key)
+}
+
+// flushLocked answers a pending question with the zone's matching records,
+// suppressing any the querier already announced as known, and must be
+// called with s.mu held.
+func (s *MDNSServer) flushLocked(key string) {
+	pending, ok := s.pending[key]
+	if !ok {
+		return
+	}
+	delete(s.pending, key)
+
+	if s.zone == nil {
+		return
+	}
+
+	candidates, ok := s.zone.lookup(pending.question.domainName, pending.question.questionType, pending.question.class())
+	if !ok {
+		return
+	}
+
+	var answers []Answer
+	for _, rec := range candidates {
+		answer := rec.toAnswer()
+		answer.answerClass |= mdnsCacheFlushBit
+		if !isKnownAnswer(answer, pending.knownAnswers) {
+			answers = append(answers, answer)
+		}
+	}
+	if len(answers) == 0 {
+		return
+	}
+
+	response := DNSResponse{
+		header: Header{QR: 1, AA: 1},
+		answer: answers,
+	}
+	buf := response.build()
+
+	if pending.question.unicastRequested() && pending.unicastAddr != nil {
+		if _, err := pending.conn.WriteToUDP(buf, pending.unicastAddr); err != nil {
+			fmt.Println("couldn't send unicast mDNS reply:", err)
+		}
+		return
+	}
+
+	if _, err := pending.conn.WriteToUDP(buf, mdnsGroupAddr(pending.conn)); err != nil {
+		fmt.Println("couldn't send multicast mDNS reply:", err)
+	}
+}
+
+func mdnsGroupAddr(conn *net.UDPConn) *net.UDPAddr {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		return &net.UDPAddr{IP: net.ParseIP(mdnsIPv6Group), Port: mdnsPort}
+	}
+	return &net.UDPAddr{IP: net.ParseIP(mdnsIPv4Group), Port: mdnsPort}
+}
+
+// isKnownAnswer reports whether known already contains candidate with a
+// TTL of at least half its true value, per the known-answer suppression
+// rule in RFC 6762 §7.1.
+func isKnownAnswer(candidate Answer, known []Answer) bool {
+	for _, k := range known {
+		if !strings.EqualFold(k.domainName, candidate.domainName) {
+			continue
+		}
+		if k.answerType != candidate.answerType || k.class() != candidate.class() {
+			continue
+		}
+		if !rdataEqual(k, candidate) {
+			continue
+		}
+		if k.TTL >= candidate.TTL/2 {
+			return true
+		}
+	}
+	return false
+}
+
+func rdataEqual(a, b Answer) bool {
+	if a.rdata == nil || b.rdata == nil {
+		return a.rdata == nil && b.rdata == nil
+	}
+	return string(a.rdata.marshal(nil, nil)) == string(b.rdata.marshal(nil, nil))
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestQuestionUnicastRequestedMasksQUBit(t *testing.T) {
+	q := Question{domainName: "printer.local", questionType: TypeA, questionClass: 1 | mdnsQUBit}
+
+	if !q.unicastRequested() {
+		t.Error("expected unicastRequested to report true when the QU bit is set")
+	}
+	if q.class() != 1 {
+		t.Errorf("expected class() to mask the QU bit off, got %d", q.class())
+	}
+}
+
+func TestAnswerCacheFlushAndGoodbye(t *testing.T) {
+	a := Answer{domainName: "printer.local", answerType: TypeA, answerClass: 1 | mdnsCacheFlushBit, TTL: 120}
+
+	if !a.cacheFlush() {
+		t.Error("expected cacheFlush to report true when the cache-flush bit is set")
+	}
+	if a.class() != 1 {
+		t.Errorf("expected class() to mask the cache-flush bit off, got %d", a.class())
+	}
+	if a.isGoodbye() {
+		t.Error("didn't expect a TTL=120 answer to be a goodbye")
+	}
+
+	a.TTL = 0
+	if !a.isGoodbye() {
+		t.Error("expected a TTL=0 answer to be a goodbye")
+	}
+}
+
+func TestIsKnownAnswerSuppressesFreshRecords(t *testing.T) {
+	aRecord, err := NewARecord("192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidate := Answer{domainName: "printer.local", answerType: TypeA, answerClass: 1, TTL: 120, rdata: aRecord}
+
+	fresh := Answer{domainName: "printer.local", answerType: TypeA, answerClass: 1, TTL: 100, rdata: aRecord}
+	if !isKnownAnswer(candidate, []Answer{fresh}) {
+		t.Error("expected a known answer with TTL over half the true TTL to suppress the candidate")
+	}
+
+	stale := Answer{domainName: "printer.local", answerType: TypeA, answerClass: 1, TTL: 10, rdata: aRecord}
+	if isKnownAnswer(candidate, []Answer{stale}) {
+		t.Error("didn't expect a known answer with TTL under half the true TTL to suppress the candidate")
+	}
+
+	otherIP, err := NewARecord("192.168.1.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	different := Answer{domainName: "printer.local", answerType: TypeA, answerClass: 1, TTL: 120, rdata: otherIP}
+	if isKnownAnswer(candidate, []Answer{different}) {
+		t.Error("didn't expect a known answer with different RDATA to suppress the candidate")
+	}
+}
+
+func TestMDNSQuestionKeyMasksQUBit(t *testing.T) {
+	withQU := Question{domainName: "printer.local", questionType: TypeA, questionClass: 1 | mdnsQUBit}
+	withoutQU := Question{domainName: "printer.local", questionType: TypeA, questionClass: 1}
+
+	if mdnsQuestionKey(withQU) != mdnsQuestionKey(withoutQU) {
+		t.Error("expected mdnsQuestionKey to be the same regardless of the QU bit")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import "fmt"
+
+// unmarshalMessage decodes a complete DNS message - header, questions, and
+// the answer/authority/additional record sections - honouring the counts
+// declared in the header. Authority and additional records share the same
+// resource-record wire format as answers, so unmarshalAnswer decodes all
+// three sections.
+func unmarshalMessage(msg []byte) (DNSResponse, error) {
+	header, err := unmarshalHeader(msg)
+	if err != nil {
+		return DNSResponse{}, err
+	}
+
+	offset := 12
+
+	questions := make([]Question, 0, header.QDCOUNT)
+	for i := 0; i < int(header.QDCOUNT); i++ {
+		question, n, err := unmarshalQuestion(msg, offset)
+		if err != nil {
+			return DNSResponse{}, fmt.Errorf("question %d: %w", i, err)
+		}
+		questions = append(questions, question)
+		offset += n
+	}
+
+	answers, offset, err := unmarshalAnswers(msg, offset, int(header.ANCOUNT))
+	if err != nil {
+		return DNSResponse{}, fmt.Errorf("answer section: %w", err)
+	}
+
+	authority, offset, err := unmarshalAnswers(msg, offset, int(header.NSCOUNT))
+	if err != nil {
+		return DNSResponse{}, fmt.Errorf("authority section: %w", err)
+	}
+
+	additional, _, err := unmarshalAnswers(msg, offset, int(header.ARCOUNT))
+	if err != nil {
+		return DNSResponse{}, fmt.Errorf("additional section: %w", err)
+	}
+
+	return DNSResponse{
+		header:     header,
+		question:   questions,
+		answer:     answers,
+		authority:  authority,
+		additional: additional,
+	}, nil
+}
+
+// buildWithTruncation builds response, dropping answers from the end until
+// the compressed message fits within maxSize, setting the TC bit if any had
+// to be dropped. The question and authority/additional sections are always
+// kept intact.
+func buildWithTruncation(response DNSResponse, maxSize int) []byte {
+	buf := response.build()
+	if len(buf) <= maxSize {
+		return buf
+	}
+
+	for len(response.answer) > 0 {
+		response.answer = response.answer[:len(response.answer)-1]
+		buf = response.build()
+		if len(buf) <= maxSize {
+			break
+		}
+	}
+
+	response.header.TC = 1
+	return response.build()
+}
+
+// unmarshalAnswers decodes count consecutive Answer-shaped records starting
+// at offset, returning the decoded records and the offset just past them.
+func unmarshalAnswers(msg []byte, offset int, count int) ([]Answer, int, error) {
+	records := make([]Answer, 0, count)
+	for i := 0; i < count; i++ {
+		record, n, err := unmarshalAnswer(msg, offset)
+		if err != nil {
+			return nil, 0, fmt.Errorf("record %d: %w", i, err)
+		}
+		records = append(records, record)
+		offset += n
+	}
+	return records, offset, nil
+}
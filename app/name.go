@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// maxNamePointers bounds how many compression pointers a single name may
+// hop through. Combined with the visited-set below this turns a pointer
+// loop into an error instead of an infinite loop.
+const maxNamePointers = 128
+
+// decodeName decodes a domain name starting at offset within the full
+// message buffer msg, following RFC 1035 §4.1.4 compression pointers.
+// It returns the decoded dotted name and the number of bytes consumed
+// from the caller's stream, i.e. starting at offset. If the name ends
+// with a pointer, bytesRead only covers the 2 pointer bytes, even though
+// the pointed-to name may be longer - the pointed-to bytes belong to
+// whatever earlier part of the message they were first written for.
+func decodeName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	visited := make(map[int]bool)
+	pos := offset
+	bytesRead := -1
+
+	for hops := 0; ; hops++ {
+		if hops > maxNamePointers {
+			return "", 0, fmt.Errorf("name decoding exceeded %d compression pointers", maxNamePointers)
+		}
+		if pos >= len(msg) {
+			return "", 0, fmt.Errorf("name decoding ran past end of message at offset %d", pos)
+		}
+
+		length := msg[pos]
+
+		if length&0xC0 == 0xC0 {
+			if pos+1 >= len(msg) {
+				return "", 0, fmt.Errorf("truncated compression pointer at offset %d", pos)
+			}
+			if bytesRead == -1 {
+				bytesRead = pos - offset + 2
+			}
+			pointer := int(binary.BigEndian.Uint16(msg[pos:pos+2]) & 0x3FFF)
+			if visited[pointer] {
+				return "", 0, fmt.Errorf("compression pointer loop detected at offset %d", pointer)
+			}
+			visited[pointer] = true
+			pos = pointer
+			continue
+		}
+
+		if length == 0 {
+			if bytesRead == -1 {
+				bytesRead = pos - offset + 1
+			}
+			break
+		}
+
+		if pos+1+int(length) > len(msg) {
+			return "", 0, fmt.Errorf("label at offset %d runs past end of message", pos)
+		}
+		labels = append(labels, string(msg[pos+1:pos+1+int(length)]))
+		pos += 1 + int(length)
+	}
+
+	return strings.Join(labels, "."), bytesRead, nil
+}
+
+// encodeNamePlain appends name to buf as a plain, uncompressed sequence of
+// length-prefixed labels terminated by a zero byte.
+func encodeNamePlain(buf []byte, name string) []byte {
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 {
+			continue
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+// NameCompressor tracks the offset (within the message being built) at
+// which each previously-written name suffix was written, so later names
+// sharing a suffix can be replaced with a pointer per RFC 1035 §4.1.4.
+type NameCompressor struct {
+	offsets map[string]uint16
+}
+
+func NewNameCompressor() *NameCompressor {
+	return &NameCompressor{offsets: make(map[string]uint16)}
+}
+
+// encodeName appends name to buf, emitting a compression pointer for the
+// longest suffix of name already written earlier in buf, if any.
+func (c *NameCompressor) encodeName(buf []byte, name string) []byte {
+	if name == "" {
+		return append(buf, 0)
+	}
+
+	labels := strings.Split(name, ".")
+	for i := 0; i < len(labels); i++ {
+		suffix := strings.Join(labels[i:], ".")
+		if offset, ok := c.offsets[suffix]; ok {
+			return append(buf, byte(0xC0|(offset>>8)), byte(offset&0xFF))
+		}
+		// Pointers can only address the first 14 bits of offset space;
+		// beyond that we just skip recording it, the name is still
+		// written out in full below.
+		if len(buf) <= 0x3FFF {
+			c.offsets[suffix] = uint16(len(buf))
+		}
+		buf = append(buf, byte(len(labels[i])))
+		buf = append(buf, labels[i]...)
+	}
+
+	return append(buf, 0)
+}
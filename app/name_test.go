@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestDecodeNamePlain(t *testing.T) {
+	msg := []byte("\x0ccodecrafters\x02io\x00")
+
+	name, bytesRead, err := decodeName(msg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "codecrafters.io" {
+		t.Errorf("expected codecrafters.io, got: %v", name)
+	}
+	if bytesRead != len(msg) {
+		t.Errorf("expected to read %d bytes, read: %v", len(msg), bytesRead)
+	}
+}
+
+func TestDecodeNamePointerOnly(t *testing.T) {
+	// "codecrafters.io" written once at offset 0, then a question whose
+	// name is nothing but a pointer back to it.
+	msg := append([]byte("\x0ccodecrafters\x02io\x00"), 0xC0, 0x00)
+	pointerOffset := 17
+
+	name, bytesRead, err := decodeName(msg, pointerOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "codecrafters.io" {
+		t.Errorf("expected codecrafters.io, got: %v", name)
+	}
+	if bytesRead != 2 {
+		t.Errorf("expected pointer-only name to consume 2 bytes, consumed: %v", bytesRead)
+	}
+}
+
+func TestDecodeNameMidPointer(t *testing.T) {
+	// "io" at offset 0, then "codecrafters" followed by a pointer to "io".
+	msg := append([]byte("\x02io\x00"), 0x0c)
+	msg = append(msg, []byte("codecrafters")...)
+	msg = append(msg, 0xC0, 0x00)
+	nameOffset := 4
+
+	name, bytesRead, err := decodeName(msg, nameOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "codecrafters.io" {
+		t.Errorf("expected codecrafters.io, got: %v", name)
+	}
+	// 1 length byte + 12 label bytes + 2 pointer bytes
+	if bytesRead != 15 {
+		t.Errorf("expected mid-name pointer to consume 15 bytes, consumed: %v", bytesRead)
+	}
+}
+
+func TestDecodeNamePointerLoop(t *testing.T) {
+	// Offset 0 points to offset 0: an immediate self-referencing loop.
+	msg := []byte{0xC0, 0x00}
+
+	_, _, err := decodeName(msg, 0)
+	if err == nil {
+		t.Fatal("expected an error for a compression pointer loop, got none")
+	}
+}
+
+func TestNameCompressorReusesSuffix(t *testing.T) {
+	compressor := NewNameCompressor()
+
+	buf := compressor.encodeName(nil, "codecrafters.io")
+	before := len(buf)
+
+	buf = compressor.encodeName(buf, "www.codecrafters.io")
+
+	// "www" label (1 length byte + 3 bytes) plus a 2-byte pointer back to
+	// the "codecrafters.io" written above - not another full name.
+	if len(buf)-before != 6 {
+		t.Errorf("expected second name to compress to 6 bytes, got: %v", len(buf)-before)
+	}
+
+	name, _, err := decodeName(buf, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "www.codecrafters.io" {
+		t.Errorf("expected www.codecrafters.io, got: %v", name)
+	}
+}
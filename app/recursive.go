@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rootHints are the well-known IPv4 addresses of the root DNS servers,
+// used as the starting point for walking the delegation chain.
+var rootHints = []string{
+	"198.41.0.4:53",     // a.root-servers.net
+	"199.9.14.201:53",   // b.root-servers.net
+	"192.33.4.12:53",    // c.root-servers.net
+	"199.7.91.13:53",    // d.root-servers.net
+	"192.203.230.10:53", // e.root-servers.net
+	"192.5.5.241:53",    // f.root-servers.net
+	"192.112.36.4:53",   // g.root-servers.net
+	"198.97.190.53:53",  // h.root-servers.net
+	"192.36.148.17:53",  // i.root-servers.net
+	"192.58.128.30:53",  // j.root-servers.net
+	"193.0.14.129:53",   // k.root-servers.net
+	"199.7.83.42:53",    // l.root-servers.net
+	"202.12.27.33:53",   // m.root-servers.net
+}
+
+const maxReferralDepth = 16
+
+type cachedAnswer struct {
+	answers []Answer
+	expires time.Time
+}
+
+// Recursive is a Resolver that walks the delegation chain itself, starting
+// from the root hints, rather than relying on a fixed upstream. It caches
+// answers keyed by question until their TTL expires.
+type Recursive struct {
+	RootHints []string
+	Timeout   time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedAnswer
+}
+
+func NewRecursive() *Recursive {
+	return &Recursive{
+		RootHints: rootHints,
+		Timeout:   defaultResolverTimeout,
+		cache:     make(map[string]cachedAnswer),
+	}
+}
+
+func (r *Recursive) Resolve(query []byte) ([]byte, error) {
+	header, err := unmarshalHeader(query)
+	if err != nil {
+		return nil, err
+	}
+	if header.QDCOUNT == 0 {
+		return nil, fmt.Errorf("query has no questions")
+	}
+
+	question, _, err := unmarshalQuestion(query, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, rcode := r.resolveAnswers(question)
+
+	response := DNSResponse{
+		header: Header{
+			ID:     header.ID,
+			QR:     1,
+			OPCODE: header.OPCODE,
+			RD:     header.RD,
+			RA:     1,
+			RCODE:  rcode,
+		},
+		question: []Question{question},
+		answer:   answers,
+	}
+
+	return response.build(), nil
+}
+
+// resolveAnswers walks the delegation chain for question, mapping any
+// resolution failure to a SERVFAIL RCODE. handleQuery calls this directly
+// (rather than Resolve) so it can assemble the response itself and apply
+// the same EDNS/truncation handling used for the zone and fallback paths.
+func (r *Recursive) resolveAnswers(question Question) ([]Answer, uint8) {
+	answers, rcode, err := r.resolveQuestion(question, r.RootHints, 0)
+	if err != nil {
+		rcode = 2 // SERVFAIL
+	}
+	return answers, rcode
+}
+
+// resolveQuestion walks the delegation chain for question, starting with
+// servers, following referrals via the glue records servers provide until
+// an authoritative answer is found or maxReferralDepth is exceeded.
+func (r *Recursive) resolveQuestion(question Question, servers []string, depth int) ([]Answer, uint8, error) {
+	if depth > maxReferralDepth {
+		return nil, 2, fmt.Errorf("exceeded max referral depth resolving %s", question.domainName)
+	}
+
+	cacheKey := fmt.Sprintf("%s|%d|%d", strings.ToLower(question.domainName), question.questionType, question.questionClass)
+	r.mu.RLock()
+	cached, ok := r.cache[cacheKey]
+	r.mu.RUnlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.answers, 0, nil
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		resp, err := r.queryServer(server, question)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if len(resp.answer) > 0 {
+			r.cacheAnswers(cacheKey, resp.answer)
+			return resp.answer, resp.header.RCODE, nil
+		}
+
+		if resp.header.RCODE == 3 { // NXDOMAIN
+			return nil, resp.header.RCODE, nil
+		}
+
+		next := glueAddresses(resp.additional)
+		if len(next) == 0 {
+			lastErr = fmt.Errorf("referral from %s for %s had no usable glue records", server, question.domainName)
+			continue
+		}
+
+		return r.resolveQuestion(question, next, depth+1)
+	}
+
+	return nil, 2, fmt.Errorf("couldn't resolve %s: %w", question.domainName, lastErr)
+}
+
+func (r *Recursive) queryServer(server string, question Question) (DNSResponse, error) {
+	query := DNSResponse{
+		header:   Header{ID: uint16(rand.Intn(1 << 16)), RD: 0},
+		question: []Question{question},
+	}
+	raw := query.build()
+
+	conn, err := net.DialTimeout("udp", server, r.Timeout)
+	if err != nil {
+		return DNSResponse{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(r.Timeout))
+
+	if _, err := conn.Write(raw); err != nil {
+		return DNSResponse{}, err
+	}
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return DNSResponse{}, err
+	}
+
+	return unmarshalMessage(buf[:n])
+}
+
+func (r *Recursive) cacheAnswers(key string, answers []Answer) {
+	minTTL := uint32(0)
+	for i, answer := range answers {
+		if i == 0 || answer.TTL < minTTL {
+			minTTL = answer.TTL
+		}
+	}
+	r.mu.Lock()
+	r.cache[key] = cachedAnswer{
+		answers: answers,
+		expires: time.Now().Add(time.Duration(minTTL) * time.Second),
+	}
+	r.mu.Unlock()
+}
+
+// glueAddresses extracts "host:53" strings for every A record offered in a
+// referral's additional section.
+func glueAddresses(additional []Answer) []string {
+	var addrs []string
+	for _, rr := range additional {
+		if a, ok := rr.rdata.(*ARecord); ok {
+			addrs = append(addrs, net.JoinHostPort(a.IP.String(), "53"))
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeDNSServer starts a UDP listener on addr that answers every query
+// with respond(header.ID, question), closing over t.Cleanup for teardown.
+func newFakeDNSServer(t *testing.T, addr string, respond func(id uint16, question Question) DNSResponse) {
+	t.Helper()
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		t.Skipf("couldn't bind fake DNS server on %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, source, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			header, err := unmarshalHeader(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, _, err := unmarshalQuestion(buf[:n], 12)
+			if err != nil {
+				continue
+			}
+			resp := respond(header.ID, question)
+			conn.WriteToUDP(resp.build(), source)
+		}
+	}()
+}
+
+func newRecursiveForTest() *Recursive {
+	r := NewRecursive()
+	r.Timeout = time.Second
+	return r
+}
+
+func TestRecursiveResolveQuestionFollowsReferral(t *testing.T) {
+	question := Question{domainName: "www.example.com", questionType: TypeA, questionClass: 1}
+
+	// The leaf authoritative server answers the question directly. Referral
+	// glue addresses always carry port 53 (glueAddresses), so the leaf has
+	// to listen there too.
+	newFakeDNSServer(t, "127.0.0.1:53", func(id uint16, q Question) DNSResponse {
+		aRecord, err := NewARecord("192.0.2.10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return DNSResponse{
+			header:   Header{ID: id, QR: 1},
+			question: []Question{q},
+			answer: []Answer{{
+				domainName:  q.domainName,
+				answerType:  TypeA,
+				answerClass: 1,
+				TTL:         60,
+				rdata:       aRecord,
+			}},
+		}
+	})
+
+	// The "root" server has no answer of its own; it refers the resolver to
+	// the leaf via an NS + glue A record in the additional section.
+	rootAddr := "127.0.0.1:15353"
+	newFakeDNSServer(t, rootAddr, func(id uint16, q Question) DNSResponse {
+		glue, err := NewARecord("127.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return DNSResponse{
+			header:   Header{ID: id, QR: 1},
+			question: []Question{q},
+			additional: []Answer{{
+				domainName:  "ns1.example.com",
+				answerType:  TypeA,
+				answerClass: 1,
+				TTL:         60,
+				rdata:       glue,
+			}},
+		}
+	})
+
+	r := newRecursiveForTest()
+	answers, rcode, err := r.resolveQuestion(question, []string{rootAddr}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcode != 0 {
+		t.Errorf("expected RCODE 0, got %d", rcode)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("expected one answer from the leaf server, got %+v", answers)
+	}
+	aRecord, ok := answers[0].rdata.(*ARecord)
+	if !ok || aRecord.IP.String() != "192.0.2.10" {
+		t.Errorf("expected 192.0.2.10, got %+v", answers[0].rdata)
+	}
+}
+
+func TestRecursiveResolveQuestionNXDOMAIN(t *testing.T) {
+	question := Question{domainName: "missing.example.com", questionType: TypeA, questionClass: 1}
+
+	addr := "127.0.0.1:15354"
+	newFakeDNSServer(t, addr, func(id uint16, q Question) DNSResponse {
+		return DNSResponse{
+			header:   Header{ID: id, QR: 1, RCODE: 3},
+			question: []Question{q},
+		}
+	})
+
+	r := newRecursiveForTest()
+	answers, rcode, err := r.resolveQuestion(question, []string{addr}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcode != 3 {
+		t.Errorf("expected NXDOMAIN (RCODE 3), got %d", rcode)
+	}
+	if len(answers) != 0 {
+		t.Errorf("expected no answers, got %+v", answers)
+	}
+}
+
+func TestRecursiveResolveQuestionDepthLimit(t *testing.T) {
+	question := Question{domainName: "example.com", questionType: TypeA, questionClass: 1}
+
+	r := newRecursiveForTest()
+	_, _, err := r.resolveQuestion(question, r.RootHints, maxReferralDepth+1)
+	if err == nil {
+		t.Fatal("expected an error once maxReferralDepth is exceeded")
+	}
+}
+
+func TestRecursiveResolveQuestionCacheHitAndExpiry(t *testing.T) {
+	question := Question{domainName: "cached.example.com", questionType: TypeA, questionClass: 1}
+	cacheKey := fmt.Sprintf("%s|%d|%d", strings.ToLower(question.domainName), question.questionType, question.questionClass)
+
+	aRecord, err := NewARecord("192.0.2.20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedAnswers := []Answer{{
+		domainName:  question.domainName,
+		answerType:  TypeA,
+		answerClass: 1,
+		TTL:         60,
+		rdata:       aRecord,
+	}}
+
+	r := newRecursiveForTest()
+	r.cache[cacheKey] = cachedAnswer{answers: cachedAnswers, expires: time.Now().Add(time.Hour)}
+
+	// No servers are reachable, so a cache hit is the only way this can
+	// succeed.
+	answers, rcode, err := r.resolveQuestion(question, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcode != 0 || len(answers) != 1 {
+		t.Fatalf("expected the cached answer to be returned untouched, got answers=%+v rcode=%d", answers, rcode)
+	}
+
+	// Once the cache entry has expired, the same question has to go back
+	// out to the servers to be re-resolved.
+	r.cache[cacheKey] = cachedAnswer{answers: cachedAnswers, expires: time.Now().Add(-time.Second)}
+
+	addr := "127.0.0.1:15355"
+	refreshed, err := NewARecord("192.0.2.21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFakeDNSServer(t, addr, func(id uint16, q Question) DNSResponse {
+		return DNSResponse{
+			header:   Header{ID: id, QR: 1},
+			question: []Question{q},
+			answer: []Answer{{
+				domainName:  q.domainName,
+				answerType:  TypeA,
+				answerClass: 1,
+				TTL:         60,
+				rdata:       refreshed,
+			}},
+		}
+	})
+
+	answers, rcode, err = r.resolveQuestion(question, []string{addr}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcode != 0 || len(answers) != 1 {
+		t.Fatalf("expected a refreshed answer, got answers=%+v rcode=%d", answers, rcode)
+	}
+	gotIP := answers[0].rdata.(*ARecord).IP.String()
+	if gotIP != "192.0.2.21" {
+		t.Errorf("expected the expired cache entry to be refreshed to 192.0.2.21, got %s", gotIP)
+	}
+}
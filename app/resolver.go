@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mode selects how the server answers queries: serving its own zone data,
+// relaying to fixed upstreams, or walking the delegation chain itself.
+type Mode int
+
+const (
+	ModeAuthoritative Mode = iota
+	ModeForward
+	ModeRecursive
+)
+
+// ServerConfig selects the resolution strategy main's query handler uses.
+type ServerConfig struct {
+	Mode      Mode
+	Upstreams []string
+	Timeout   time.Duration
+}
+
+// Resolver answers a raw client query with a raw response message, in
+// whatever way its implementation sees fit (forwarding, walking the
+// delegation chain, serving local zone data, ...).
+type Resolver interface {
+	Resolve(query []byte) ([]byte, error)
+}
+
+const defaultResolverTimeout = 2 * time.Second
+
+// loadUpstreamsFromResolvConf parses "nameserver" lines out of a
+// resolv.conf(5)-formatted file.
+func loadUpstreamsFromResolvConf(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var upstreams []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "nameserver" {
+			upstreams = append(upstreams, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %w", path, err)
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no nameserver entries found in %s", path)
+	}
+
+	return upstreams, nil
+}
+
+// Forwarder is a Resolver that relays every query verbatim to a fixed list
+// of upstream servers, trying each in turn. Each upstream attempt retries
+// with exponential backoff, and automatically falls back to TCP when the
+// UDP reply has the truncation (TC) bit set.
+type Forwarder struct {
+	Upstreams  []string
+	Timeout    time.Duration
+	MaxRetries int
+}
+
+func NewForwarder(upstreams []string) *Forwarder {
+	return &Forwarder{
+		Upstreams:  upstreams,
+		Timeout:    defaultResolverTimeout,
+		MaxRetries: 3,
+	}
+}
+
+func (f *Forwarder) Resolve(query []byte) ([]byte, error) {
+	if len(query) < 12 {
+		return nil, fmt.Errorf("query of %d bytes is too short to forward", len(query))
+	}
+
+	// Forward under our own query ID so concurrent client queries can't be
+	// confused with one another upstream, then restore the client's ID.
+	clientID := [2]byte{query[0], query[1]}
+	forwarded := append([]byte{}, query...)
+	upstreamID := uint16(rand.Intn(1 << 16))
+	forwarded[0] = byte(upstreamID >> 8)
+	forwarded[1] = byte(upstreamID & 0xFF)
+
+	var lastErr error
+	for _, upstream := range f.Upstreams {
+		resp, err := f.exchangeWithRetry(upstream, forwarded)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(resp) < 2 || resp[0] != forwarded[0] || resp[1] != forwarded[1] {
+			lastErr = fmt.Errorf("upstream %s: reply ID didn't match query ID %d", upstream, upstreamID)
+			continue
+		}
+		resp[0], resp[1] = clientID[0], clientID[1]
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("all upstreams failed, last error: %w", lastErr)
+}
+
+func (f *Forwarder) exchangeWithRetry(upstream string, query []byte) ([]byte, error) {
+	backoff := 100 * time.Millisecond
+	var lastErr error
+
+	for attempt := 0; attempt < f.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		resp, err := f.exchangeUDP(upstream, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		header, err := unmarshalHeader(resp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if header.TC == 1 {
+			resp, err = f.exchangeTCP(upstream, query)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("upstream %s: %w", upstream, lastErr)
+}
+
+func (f *Forwarder) exchangeUDP(upstream string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", upstream, f.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(f.Timeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+func (f *Forwarder) exchangeTCP(upstream string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", upstream, f.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(f.Timeout))
+
+	if err := writeTCPMessage(conn, query); err != nil {
+		return nil, err
+	}
+
+	return readTCPMessage(conn)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadUpstreamsFromResolvConf(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	contents := "# comment\nnameserver 1.1.1.1\nsearch example.com\nnameserver 8.8.8.8\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	upstreams, err := loadUpstreamsFromResolvConf(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"1.1.1.1:53", "8.8.8.8:53"}
+	if !reflect.DeepEqual(upstreams, expected) {
+		t.Errorf("expected %v, got %v", expected, upstreams)
+	}
+}
+
+func TestLoadUpstreamsFromResolvConfNoEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	if err := os.WriteFile(path, []byte("search example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadUpstreamsFromResolvConf(path); err == nil {
+		t.Fatal("expected an error when resolv.conf has no nameserver entries")
+	}
+}
+
+func TestGlueAddresses(t *testing.T) {
+	aRecord, err := NewARecord("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	additional := []Answer{
+		{domainName: "ns1.example.com", answerType: TypeA, rdata: aRecord},
+		{domainName: "ns1.example.com", answerType: TypeNS, rdata: &NSRecord{Host: "ns2.example.com"}},
+	}
+
+	addrs := glueAddresses(additional)
+	expected := []string{"192.0.2.1:53"}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expected %v, got %v", expected, addrs)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Record type values, RFC 1035 §3.2.2 (plus AAAA from RFC 3596).
+const (
+	TypeA     uint16 = 1
+	TypeNS    uint16 = 2
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypePTR   uint16 = 12
+	TypeMX    uint16 = 15
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+)
+
+// RR is implemented by every resource record's RDATA. marshal appends the
+// encoded RDATA to buf; compressor may be nil, in which case any names
+// inside the RDATA are written out in full rather than pointer-compressed.
+// unmarshal decodes the RDATA occupying msg[offset : offset+rdlength].
+type RR interface {
+	marshal(buf []byte, compressor *NameCompressor) []byte
+	unmarshal(msg []byte, offset int, rdlength uint16) error
+}
+
+// newRR returns a zero-valued RR for answerType, ready to have unmarshal
+// called on it. Unrecognised types fall back to UnknownRecord so the
+// server can still forward/relay RDATA it doesn't understand.
+func newRR(answerType uint16) RR {
+	switch answerType {
+	case TypeA:
+		return &ARecord{}
+	case TypeAAAA:
+		return &AAAARecord{}
+	case TypeCNAME:
+		return &CNAMERecord{}
+	case TypeNS:
+		return &NSRecord{}
+	case TypeMX:
+		return &MXRecord{}
+	case TypeTXT:
+		return &TXTRecord{}
+	case TypeSOA:
+		return &SOARecord{}
+	case TypePTR:
+		return &PTRRecord{}
+	case TypeOPT:
+		return &OPT{}
+	default:
+		return &UnknownRecord{}
+	}
+}
+
+// ARecord is a TYPE=1 host address RDATA: a 4-byte IPv4 address.
+type ARecord struct {
+	IP net.IP
+}
+
+func NewARecord(ip string) (*ARecord, error) {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		return nil, fmt.Errorf("%q is not a valid IPv4 address", ip)
+	}
+	return &ARecord{IP: parsed}, nil
+}
+
+func (r *ARecord) marshal(buf []byte, _ *NameCompressor) []byte {
+	return append(buf, r.IP.To4()...)
+}
+
+func (r *ARecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	if rdlength != 4 {
+		return fmt.Errorf("A record RDLENGTH must be 4, got %d", rdlength)
+	}
+	r.IP = net.IP(append([]byte{}, msg[offset:offset+4]...))
+	return nil
+}
+
+// AAAARecord is a TYPE=28 host address RDATA: a 16-byte IPv6 address.
+type AAAARecord struct {
+	IP net.IP
+}
+
+func NewAAAARecord(ip string) (*AAAARecord, error) {
+	parsed := net.ParseIP(ip).To16()
+	if parsed == nil {
+		return nil, fmt.Errorf("%q is not a valid IPv6 address", ip)
+	}
+	return &AAAARecord{IP: parsed}, nil
+}
+
+func (r *AAAARecord) marshal(buf []byte, _ *NameCompressor) []byte {
+	return append(buf, r.IP.To16()...)
+}
+
+func (r *AAAARecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	if rdlength != 16 {
+		return fmt.Errorf("AAAA record RDLENGTH must be 16, got %d", rdlength)
+	}
+	r.IP = net.IP(append([]byte{}, msg[offset:offset+16]...))
+	return nil
+}
+
+// marshalName writes name via compressor when one is given, else in full.
+func marshalName(buf []byte, compressor *NameCompressor, name string) []byte {
+	if compressor != nil {
+		return compressor.encodeName(buf, name)
+	}
+	return encodeNamePlain(buf, name)
+}
+
+// CNAMERecord is a TYPE=5 canonical name RDATA.
+type CNAMERecord struct {
+	Target string
+}
+
+func (r *CNAMERecord) marshal(buf []byte, compressor *NameCompressor) []byte {
+	return marshalName(buf, compressor, r.Target)
+}
+
+func (r *CNAMERecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	name, _, err := decodeName(msg, offset)
+	if err != nil {
+		return err
+	}
+	r.Target = name
+	return nil
+}
+
+// NSRecord is a TYPE=2 authoritative name server RDATA.
+type NSRecord struct {
+	Host string
+}
+
+func (r *NSRecord) marshal(buf []byte, compressor *NameCompressor) []byte {
+	return marshalName(buf, compressor, r.Host)
+}
+
+func (r *NSRecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	name, _, err := decodeName(msg, offset)
+	if err != nil {
+		return err
+	}
+	r.Host = name
+	return nil
+}
+
+// PTRRecord is a TYPE=12 domain name pointer RDATA.
+type PTRRecord struct {
+	Target string
+}
+
+func (r *PTRRecord) marshal(buf []byte, compressor *NameCompressor) []byte {
+	return marshalName(buf, compressor, r.Target)
+}
+
+func (r *PTRRecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	name, _, err := decodeName(msg, offset)
+	if err != nil {
+		return err
+	}
+	r.Target = name
+	return nil
+}
+
+// MXRecord is a TYPE=15 mail exchange RDATA.
+type MXRecord struct {
+	Preference uint16
+	Exchange   string
+}
+
+func (r *MXRecord) marshal(buf []byte, compressor *NameCompressor) []byte {
+	buf = append(buf, byte(r.Preference>>8), byte(r.Preference&0xFF))
+	return marshalName(buf, compressor, r.Exchange)
+}
+
+func (r *MXRecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	if rdlength < 3 {
+		return fmt.Errorf("MX record RDLENGTH too short: %d", rdlength)
+	}
+	r.Preference = binary.BigEndian.Uint16(msg[offset : offset+2])
+	name, _, err := decodeName(msg, offset+2)
+	if err != nil {
+		return err
+	}
+	r.Exchange = name
+	return nil
+}
+
+// TXTRecord is a TYPE=16 text strings RDATA: one or more <len><bytes> runs.
+type TXTRecord struct {
+	Strings []string
+}
+
+func (r *TXTRecord) marshal(buf []byte, _ *NameCompressor) []byte {
+	for _, s := range r.Strings {
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func (r *TXTRecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	end := offset + int(rdlength)
+	pos := offset
+	for pos < end {
+		length := int(msg[pos])
+		pos++
+		if pos+length > end {
+			return fmt.Errorf("TXT string at offset %d runs past RDATA end", pos)
+		}
+		r.Strings = append(r.Strings, string(msg[pos:pos+length]))
+		pos += length
+	}
+	return nil
+}
+
+// SOARecord is a TYPE=6 start-of-authority RDATA.
+type SOARecord struct {
+	MNAME   string
+	RNAME   string
+	SERIAL  uint32
+	REFRESH uint32
+	RETRY   uint32
+	EXPIRE  uint32
+	MINIMUM uint32
+}
+
+func (r *SOARecord) marshal(buf []byte, compressor *NameCompressor) []byte {
+	buf = marshalName(buf, compressor, r.MNAME)
+	buf = marshalName(buf, compressor, r.RNAME)
+	buf = append(buf, byte(r.SERIAL>>24), byte(r.SERIAL>>16), byte(r.SERIAL>>8), byte(r.SERIAL))
+	buf = append(buf, byte(r.REFRESH>>24), byte(r.REFRESH>>16), byte(r.REFRESH>>8), byte(r.REFRESH))
+	buf = append(buf, byte(r.RETRY>>24), byte(r.RETRY>>16), byte(r.RETRY>>8), byte(r.RETRY))
+	buf = append(buf, byte(r.EXPIRE>>24), byte(r.EXPIRE>>16), byte(r.EXPIRE>>8), byte(r.EXPIRE))
+	buf = append(buf, byte(r.MINIMUM>>24), byte(r.MINIMUM>>16), byte(r.MINIMUM>>8), byte(r.MINIMUM))
+	return buf
+}
+
+func (r *SOARecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	mname, mnameBytes, err := decodeName(msg, offset)
+	if err != nil {
+		return err
+	}
+	rname, rnameBytes, err := decodeName(msg, offset+mnameBytes)
+	if err != nil {
+		return err
+	}
+	pos := offset + mnameBytes + rnameBytes
+	if pos+20 > len(msg) {
+		return fmt.Errorf("SOA record at offset %d is missing trailing fields", offset)
+	}
+	r.MNAME = mname
+	r.RNAME = rname
+	r.SERIAL = binary.BigEndian.Uint32(msg[pos : pos+4])
+	r.REFRESH = binary.BigEndian.Uint32(msg[pos+4 : pos+8])
+	r.RETRY = binary.BigEndian.Uint32(msg[pos+8 : pos+12])
+	r.EXPIRE = binary.BigEndian.Uint32(msg[pos+12 : pos+16])
+	r.MINIMUM = binary.BigEndian.Uint32(msg[pos+16 : pos+20])
+	return nil
+}
+
+// UnknownRecord preserves the raw RDATA bytes of a record type this
+// package has no dedicated decoder for, so unsupported records can still
+// be relayed (e.g. by a forwarding resolver) without being dropped.
+type UnknownRecord struct {
+	RDATA []byte
+}
+
+func (r *UnknownRecord) marshal(buf []byte, _ *NameCompressor) []byte {
+	return append(buf, r.RDATA...)
+}
+
+func (r *UnknownRecord) unmarshal(msg []byte, offset int, rdlength uint16) error {
+	r.RDATA = append([]byte{}, msg[offset:offset+int(rdlength)]...)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCNAMERecordRoundTrip(t *testing.T) {
+	record := CNAMERecord{Target: "codecrafters.io"}
+
+	buf := record.marshal(nil, nil)
+
+	decoded := CNAMERecord{}
+	if err := decoded.unmarshal(buf, 0, uint16(len(buf))); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Target != record.Target {
+		t.Errorf("expected target %q, got %q", record.Target, decoded.Target)
+	}
+}
+
+func TestMXRecordRoundTrip(t *testing.T) {
+	record := MXRecord{Preference: 10, Exchange: "mail.codecrafters.io"}
+
+	buf := record.marshal(nil, nil)
+
+	decoded := MXRecord{}
+	if err := decoded.unmarshal(buf, 0, uint16(len(buf))); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Preference != record.Preference {
+		t.Errorf("expected preference %d, got %d", record.Preference, decoded.Preference)
+	}
+	if decoded.Exchange != record.Exchange {
+		t.Errorf("expected exchange %q, got %q", record.Exchange, decoded.Exchange)
+	}
+}
+
+func TestTXTRecordRoundTrip(t *testing.T) {
+	record := TXTRecord{Strings: []string{"v=spf1 -all", "more text"}}
+
+	buf := record.marshal(nil, nil)
+
+	decoded := TXTRecord{}
+	if err := decoded.unmarshal(buf, 0, uint16(len(buf))); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.Strings, record.Strings) {
+		t.Errorf("expected strings %v, got %v", record.Strings, decoded.Strings)
+	}
+}
+
+func TestSOARecordRoundTrip(t *testing.T) {
+	record := SOARecord{
+		MNAME:   "ns1.codecrafters.io",
+		RNAME:   "admin.codecrafters.io",
+		SERIAL:  2024010100,
+		REFRESH: 3600,
+		RETRY:   600,
+		EXPIRE:  1209600,
+		MINIMUM: 300,
+	}
+
+	buf := record.marshal(nil, nil)
+
+	decoded := SOARecord{}
+	if err := decoded.unmarshal(buf, 0, uint16(len(buf))); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, record) {
+		t.Errorf("expected %+v, got %+v", record, decoded)
+	}
+}
+
+func TestAAAARecordRoundTrip(t *testing.T) {
+	record, err := NewAAAARecord("2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := record.marshal(nil, nil)
+	if len(buf) != 16 {
+		t.Fatalf("expected AAAA RDATA to be 16 bytes, got %d", len(buf))
+	}
+
+	decoded := AAAARecord{}
+	if err := decoded.unmarshal(buf, 0, uint16(len(buf))); err != nil {
+		t.Fatal(err)
+	}
+
+	if !decoded.IP.Equal(record.IP) {
+		t.Errorf("expected IP %v, got %v", record.IP, decoded.IP)
+	}
+}
+
+func TestAnswerMarshalCompressesRDATANames(t *testing.T) {
+	// A question for "codecrafters.io" followed by a CNAME answer whose
+	// target is the same name should have its RDATA pointer-compressed
+	// back to the question instead of spelling the name out again.
+	compressor := NewNameCompressor()
+	question := Question{domainName: "codecrafters.io", questionType: TypeCNAME, questionClass: 1}
+	buf := question.marshalWithCompression(nil, compressor)
+
+	answer := Answer{
+		domainName:  "www.codecrafters.io",
+		answerType:  TypeCNAME,
+		answerClass: 1,
+		TTL:         60,
+		rdata:       &CNAMERecord{Target: "codecrafters.io"},
+	}
+	buf = answer.marshalWithCompression(buf, compressor)
+
+	decoded, _, err := decodeName(buf, len(buf)-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != "codecrafters.io" {
+		t.Fatalf("expected decoded RDATA name codecrafters.io, got %q", decoded)
+	}
+	if buf[len(buf)-2]&0xC0 != 0xC0 {
+		t.Errorf("expected RDATA name to end in a compression pointer, last 2 bytes: %x", buf[len(buf)-2:])
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// tcpIdleTimeout closes a TCP connection that sits idle between queries for
+// longer than this, so a client holding the connection open doesn't leak it
+// forever.
+const tcpIdleTimeout = 30 * time.Second
+
+// writeTCPMessage writes msg to w prefixed with its 2-byte big-endian
+// length, per RFC 1035 §4.2.2.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	if len(msg) > 0xFFFF {
+		return fmt.Errorf("message of %d bytes is too large for TCP length framing", len(msg))
+	}
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(msg)))
+
+	if _, err := w.Write(length); err != nil {
+		return fmt.Errorf("couldn't write TCP length prefix: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("couldn't write TCP message: %w", err)
+	}
+	return nil
+}
+
+// readTCPMessage reads one length-prefixed DNS message from r, per RFC 1035
+// §4.2.2.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, fmt.Errorf("couldn't read TCP length prefix: %w", err)
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("couldn't read TCP message: %w", err)
+	}
+	return msg, nil
+}
+
+// serveTCP accepts connections on listener forever, handling each on its
+// own goroutine so one slow client can't block the others.
+func serveTCP(listener *net.TCPListener, resolver Resolver, zone *Zone) {
+	for {
+		conn, err := listener.AcceptTCP()
+		if err != nil {
+			fmt.Println("couldn't accept TCP connection:", err)
+			continue
+		}
+		go handleTCPConn(conn, resolver, zone)
+	}
+}
+
+// handleTCPConn serves queries off conn until the client closes it or it
+// sits idle past tcpIdleTimeout, supporting multiple queries per connection
+// per RFC 1035 §4.2.2.
+func handleTCPConn(conn *net.TCPConn, resolver Resolver, zone *Zone) {
+	defer conn.Close()
+
+	for {
+		conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+
+		resp := handleQuery(query, resolver, zone, true)
+		if resp == nil {
+			continue
+		}
+
+		if err := writeTCPMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
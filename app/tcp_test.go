@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTCPMessageRoundTrip(t *testing.T) {
+	msg := []byte("\x0ccodecrafters\x02io\x00\x00\x01\x00\x01")
+
+	var buf bytes.Buffer
+	if err := writeTCPMessage(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := readTCPMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded, msg) {
+		t.Errorf("expected %v, got %v", msg, decoded)
+	}
+}
+
+func TestTCPMessageRoundTripMultiple(t *testing.T) {
+	first := []byte("\x02io\x00\x00\x01\x00\x01")
+	second := []byte("\x0ccodecrafters\x02io\x00\x00\x01\x00\x01")
+
+	var buf bytes.Buffer
+	if err := writeTCPMessage(&buf, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTCPMessage(&buf, second); err != nil {
+		t.Fatal(err)
+	}
+
+	decodedFirst, err := readTCPMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedSecond, err := readTCPMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decodedFirst, first) {
+		t.Errorf("expected first message %v, got %v", first, decodedFirst)
+	}
+	if !bytes.Equal(decodedSecond, second) {
+		t.Errorf("expected second message %v, got %v", second, decodedSecond)
+	}
+}
+
+func TestHandleQueryTCPSkipsFiveTwelveByteCap(t *testing.T) {
+	query := Question{domainName: "codecrafters.io", questionType: TypeA, questionClass: 1}
+	header := Header{ID: 1, QDCOUNT: 1}
+	msg := append(header.marshal(), query.marshal()...)
+
+	resp := handleQuery(msg, nil, nil, true)
+	if resp == nil {
+		t.Fatal("expected a response")
+	}
+
+	decoded, err := unmarshalMessage(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.header.TC != 0 {
+		t.Error("expected TC to be unset for a small response over TCP")
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const maxCNAMEChainLength = 16
+
+// rrKey identifies a group of resource records sharing a name, type and
+// class - the unit a question is looked up by.
+type rrKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// ZoneRecord is one record loaded from a master file.
+type ZoneRecord struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	RR    RR
+}
+
+func (rec ZoneRecord) toAnswer() Answer {
+	return Answer{
+		domainName:  rec.Name,
+		answerType:  rec.Type,
+		answerClass: rec.Class,
+		TTL:         rec.TTL,
+		rdata:       rec.RR,
+	}
+}
+
+// Zone is an in-memory, fully-qualified-name-keyed view of a master file
+// loaded per RFC 1035 §5.
+type Zone struct {
+	Origin  string
+	records map[rrKey][]ZoneRecord
+}
+
+func NewZone(origin string) *Zone {
+	return &Zone{
+		Origin:  strings.TrimSuffix(origin, "."),
+		records: make(map[rrKey][]ZoneRecord),
+	}
+}
+
+func (z *Zone) add(rec ZoneRecord) {
+	key := rrKey{name: strings.ToLower(rec.Name), qtype: rec.Type, qclass: rec.Class}
+	z.records[key] = append(z.records[key], rec)
+}
+
+func (z *Zone) lookup(name string, qtype uint16, qclass uint16) ([]ZoneRecord, bool) {
+	recs, ok := z.records[rrKey{name: strings.ToLower(name), qtype: qtype, qclass: qclass}]
+	return recs, ok
+}
+
+// hasName reports whether any record exists for name, regardless of type -
+// this is what distinguishes "NODATA" from "NXDOMAIN".
+func (z *Zone) hasName(name string) bool {
+	lname := strings.ToLower(name)
+	for key := range z.records {
+		if key.name == lname {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve answers question against the zone, following CNAME chains and
+// assembling the NS/SOA authority section and A/AAAA glue. rcode is
+// RCODE=3 (NXDOMAIN) only when no record at all matches the final name in
+// the chain, per RFC 1035 §4.1.1.
+func (z *Zone) Resolve(question Question) (answers, authority, additional []Answer, rcode uint8) {
+	name := question.domainName
+	visited := make(map[string]bool)
+	// resolved tracks whether the terminal name in the CNAME chain actually
+	// matched a record, as distinct from len(answers) != 0, which is also
+	// true when the chain dead-ends on a CNAME whose target doesn't exist.
+	resolved := false
+
+	for i := 0; i < maxCNAMEChainLength; i++ {
+		if recs, ok := z.lookup(name, question.questionType, question.questionClass); ok {
+			for _, rec := range recs {
+				answers = append(answers, rec.toAnswer())
+			}
+			resolved = true
+			break
+		}
+
+		cnameRecs, ok := z.lookup(name, TypeCNAME, question.questionClass)
+		if !ok || len(cnameRecs) == 0 {
+			break
+		}
+		rec := cnameRecs[0]
+		answers = append(answers, rec.toAnswer())
+
+		target := rec.RR.(*CNAMERecord).Target
+		if visited[target] {
+			break
+		}
+		visited[target] = true
+		name = target
+	}
+
+	if !resolved && !z.hasName(name) {
+		rcode = 3
+		if soaRecs, ok := z.lookup(z.Origin, TypeSOA, question.questionClass); ok && len(soaRecs) > 0 {
+			authority = append(authority, soaRecs[0].toAnswer())
+		}
+		return
+	}
+
+	if nsRecs, ok := z.lookup(z.Origin, TypeNS, question.questionClass); ok {
+		for _, rec := range nsRecs {
+			authority = append(authority, rec.toAnswer())
+
+			host := rec.RR.(*NSRecord).Host
+			if glue, ok := z.lookup(host, TypeA, question.questionClass); ok {
+				for _, g := range glue {
+					additional = append(additional, g.toAnswer())
+				}
+			}
+			if glue, ok := z.lookup(host, TypeAAAA, question.questionClass); ok {
+				for _, g := range glue {
+					additional = append(additional, g.toAnswer())
+				}
+			}
+		}
+	}
+
+	return answers, authority, additional, 0
+}
+
+// LoadZone reads a master file from path per RFC 1035 §5, including
+// $ORIGIN, $TTL and $GENERATE directives.
+func LoadZone(path string) (*Zone, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open zone file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parseZone(file)
+}
+
+func parseZone(r io.Reader) (*Zone, error) {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read zone file: %w", err)
+	}
+
+	lines := tokenizeMasterFile(string(contents))
+
+	p := &zoneParser{}
+	for _, fields := range lines {
+		if len(fields) == 0 {
+			continue
+		}
+		if p.zone == nil {
+			p.zone = NewZone("")
+		}
+		if err := p.parseLine(fields); err != nil {
+			return nil, err
+		}
+	}
+	if p.zone == nil {
+		p.zone = NewZone("")
+	}
+	return p.zone, nil
+}
+
+// zoneParser carries the directive state ($ORIGIN, $TTL, last name) that
+// applies across lines while a master file is parsed.
+type zoneParser struct {
+	zone       *Zone
+	origin     string
+	defaultTTL uint32
+	lastName   string
+}
+
+func (p *zoneParser) parseLine(fields []string) error {
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+		if len(fields) < 2 {
+			return fmt.Errorf("$ORIGIN needs a domain name")
+		}
+		p.origin = absoluteName(fields[1], p.origin)
+		p.lastName = p.origin
+		if p.zone.Origin == "" {
+			p.zone.Origin = p.origin
+		}
+		return nil
+
+	case "$TTL":
+		if len(fields) < 2 {
+			return fmt.Errorf("$TTL needs a value")
+		}
+		ttl, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid $TTL value %q: %w", fields[1], err)
+		}
+		p.defaultTTL = uint32(ttl)
+		return nil
+
+	case "$GENERATE":
+		return p.generate(fields[1:])
+	}
+
+	return p.parseRecord(fields)
+}
+
+func (p *zoneParser) parseRecord(fields []string) error {
+	pos := 0
+	name := p.lastName
+	if !isTTLToken(fields[0]) && !isClassToken(fields[0]) && !isTypeToken(fields[0]) {
+		name = fields[0]
+		pos = 1
+	}
+	name = absoluteName(name, p.origin)
+	p.lastName = name
+
+	ttl := p.defaultTTL
+	class := uint16(1) // IN; this loader doesn't support other classes.
+	for pos < len(fields) {
+		switch {
+		case isTTLToken(fields[pos]):
+			n, err := strconv.ParseUint(fields[pos], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid TTL %q: %w", fields[pos], err)
+			}
+			ttl = uint32(n)
+			pos++
+		case isClassToken(fields[pos]):
+			pos++
+		default:
+			goto haveTypeToken
+		}
+	}
+haveTypeToken:
+	if pos >= len(fields) {
+		return fmt.Errorf("record for %s is missing a type", name)
+	}
+	typeToken := strings.ToUpper(fields[pos])
+	pos++
+
+	rrType, rr, err := buildRRFromTokens(typeToken, fields[pos:], p.origin)
+	if err != nil {
+		return fmt.Errorf("%s record for %s: %w", typeToken, name, err)
+	}
+
+	p.zone.add(ZoneRecord{Name: name, Type: rrType, Class: class, TTL: ttl, RR: rr})
+	return nil
+}
+
+// generate synthesizes records for a range per $GENERATE, e.g.
+// "$GENERATE 1-100 host$ A 10.0.0.$" creates host1..host100 A records.
+func (p *zoneParser) generate(fields []string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("$GENERATE needs a range, a name template and a type")
+	}
+
+	start, stop, step, err := parseGenerateRange(fields[0])
+	if err != nil {
+		return err
+	}
+	nameTemplate := fields[1]
+	typeToken := strings.ToUpper(fields[2])
+	rdataTemplates := fields[3:]
+
+	for i := start; i <= stop; i += step {
+		name := absoluteName(substituteGenerateIndex(nameTemplate, i), p.origin)
+
+		rdataTokens := make([]string, len(rdataTemplates))
+		for j, tmpl := range rdataTemplates {
+			rdataTokens[j] = substituteGenerateIndex(tmpl, i)
+		}
+
+		rrType, rr, err := buildRRFromTokens(typeToken, rdataTokens, p.origin)
+		if err != nil {
+			return fmt.Errorf("$GENERATE %s record for %s: %w", typeToken, name, err)
+		}
+
+		p.zone.add(ZoneRecord{Name: name, Type: rrType, Class: 1, TTL: p.defaultTTL, RR: rr})
+	}
+
+	return nil
+}
+
+func parseGenerateRange(spec string) (start, stop, step int, err error) {
+	step = 1
+	rangeSpec := spec
+	if idx := strings.IndexByte(spec, '/'); idx != -1 {
+		step, err = strconv.Atoi(spec[idx+1:])
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid $GENERATE step in %q: %w", spec, err)
+		}
+		rangeSpec = spec[:idx]
+	}
+
+	bounds := strings.SplitN(rangeSpec, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", spec)
+	}
+	if start, err = strconv.Atoi(bounds[0]); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range start in %q: %w", spec, err)
+	}
+	if stop, err = strconv.Atoi(bounds[1]); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range end in %q: %w", spec, err)
+	}
+
+	return start, stop, step, nil
+}
+
+func substituteGenerateIndex(template string, i int) string {
+	return strings.ReplaceAll(template, "$", strconv.Itoa(i))
+}
+
+// buildRRFromTokens builds the RR type value and RDATA for a record's
+// already-split type keyword and remaining text tokens.
+func buildRRFromTokens(typeToken string, tokens []string, origin string) (uint16, RR, error) {
+	switch typeToken {
+	case "A":
+		if len(tokens) < 1 {
+			return 0, nil, fmt.Errorf("missing address")
+		}
+		rr, err := NewARecord(tokens[0])
+		return TypeA, rr, err
+
+	case "AAAA":
+		if len(tokens) < 1 {
+			return 0, nil, fmt.Errorf("missing address")
+		}
+		rr, err := NewAAAARecord(tokens[0])
+		return TypeAAAA, rr, err
+
+	case "NS":
+		if len(tokens) < 1 {
+			return 0, nil, fmt.Errorf("missing host")
+		}
+		return TypeNS, &NSRecord{Host: absoluteName(tokens[0], origin)}, nil
+
+	case "CNAME":
+		if len(tokens) < 1 {
+			return 0, nil, fmt.Errorf("missing target")
+		}
+		return TypeCNAME, &CNAMERecord{Target: absoluteName(tokens[0], origin)}, nil
+
+	case "PTR":
+		if len(tokens) < 1 {
+			return 0, nil, fmt.Errorf("missing target")
+		}
+		return TypePTR, &PTRRecord{Target: absoluteName(tokens[0], origin)}, nil
+
+	case "MX":
+		if len(tokens) < 2 {
+			return 0, nil, fmt.Errorf("missing preference or exchange")
+		}
+		pref, err := strconv.ParseUint(tokens[0], 10, 16)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid preference %q: %w", tokens[0], err)
+		}
+		return TypeMX, &MXRecord{Preference: uint16(pref), Exchange: absoluteName(tokens[1], origin)}, nil
+
+	case "TXT":
+		strs := make([]string, len(tokens))
+		for i, tok := range tokens {
+			strs[i] = strings.Trim(tok, `"`)
+		}
+		return TypeTXT, &TXTRecord{Strings: strs}, nil
+
+	case "SOA":
+		if len(tokens) < 7 {
+			return 0, nil, fmt.Errorf("SOA needs MNAME RNAME SERIAL REFRESH RETRY EXPIRE MINIMUM")
+		}
+		serial, err := strconv.ParseUint(tokens[2], 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid SERIAL %q: %w", tokens[2], err)
+		}
+		refresh, err := strconv.ParseUint(tokens[3], 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid REFRESH %q: %w", tokens[3], err)
+		}
+		retry, err := strconv.ParseUint(tokens[4], 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid RETRY %q: %w", tokens[4], err)
+		}
+		expire, err := strconv.ParseUint(tokens[5], 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid EXPIRE %q: %w", tokens[5], err)
+		}
+		minimum, err := strconv.ParseUint(tokens[6], 10, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid MINIMUM %q: %w", tokens[6], err)
+		}
+		return TypeSOA, &SOARecord{
+			MNAME:   absoluteName(tokens[0], origin),
+			RNAME:   absoluteName(tokens[1], origin),
+			SERIAL:  uint32(serial),
+			REFRESH: uint32(refresh),
+			RETRY:   uint32(retry),
+			EXPIRE:  uint32(expire),
+			MINIMUM: uint32(minimum),
+		}, nil
+
+	default:
+		return 0, nil, fmt.Errorf("unsupported record type %q", typeToken)
+	}
+}
+
+// absoluteName resolves a master-file name relative to origin: "@" is the
+// origin itself, a trailing-dot name is already fully qualified, and
+// anything else is relative to origin.
+func absoluteName(name string, origin string) string {
+	if name == "@" || name == "" {
+		return origin
+	}
+	if strings.HasSuffix(name, ".") {
+		return strings.TrimSuffix(name, ".")
+	}
+	if origin == "" {
+		return name
+	}
+	return name + "." + origin
+}
+
+func isTTLToken(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	for _, c := range tok {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isClassToken(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "IN", "CS", "CH", "HS":
+		return true
+	default:
+		return false
+	}
+}
+
+func isTypeToken(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "A", "AAAA", "NS", "CNAME", "PTR", "MX", "TXT", "SOA":
+		return true
+	default:
+		return false
+	}
+}
+
+// tokenizeMasterFile strips ;-comments, joins parenthesized continuations
+// into a single logical line, and splits each logical line into
+// whitespace-separated fields, keeping "quoted strings" intact as one
+// field.
+func tokenizeMasterFile(contents string) [][]string {
+	var lines [][]string
+	var current []string
+	var field strings.Builder
+	inQuotes := false
+	parenDepth := 0
+
+	flushField := func() {
+		if field.Len() > 0 {
+			current = append(current, field.String())
+			field.Reset()
+		}
+	}
+	flushLine := func() {
+		flushField()
+		if len(current) > 0 {
+			lines = append(lines, current)
+			current = nil
+		}
+	}
+
+	runes := []rune(contents)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case inQuotes:
+			field.WriteRune(c)
+			if c == '"' {
+				inQuotes = false
+			}
+		case c == '"':
+			inQuotes = true
+			field.WriteRune(c)
+		case c == ';':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '(':
+			parenDepth++
+		case c == ')':
+			if parenDepth > 0 {
+				parenDepth--
+			}
+		case c == '\n':
+			flushField()
+			if parenDepth == 0 {
+				flushLine()
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			flushField()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	flushLine()
+
+	return lines
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testZoneFile = `
+$ORIGIN example.com.
+$TTL 3600
+@       IN SOA  ns1.example.com. admin.example.com. (
+                2024010100 ; serial
+                3600       ; refresh
+                600        ; retry
+                1209600    ; expire
+                300 )      ; minimum
+@       IN NS   ns1
+ns1     IN A    192.0.2.1
+www     IN A    192.0.2.10
+alias   IN CNAME www
+dangling IN CNAME nowhere
+$GENERATE 1-3 host$ A 10.0.0.$
+`
+
+func TestParseZoneBasicRecords(t *testing.T) {
+	zone, err := parseZone(strings.NewReader(testZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if zone.Origin != "example.com" {
+		t.Errorf("expected origin example.com, got %q", zone.Origin)
+	}
+
+	recs, ok := zone.lookup("www.example.com", TypeA, 1)
+	if !ok || len(recs) != 1 {
+		t.Fatalf("expected one A record for www.example.com, got %+v", recs)
+	}
+	aRecord, ok := recs[0].RR.(*ARecord)
+	if !ok || aRecord.IP.String() != "192.0.2.10" {
+		t.Errorf("expected www.example.com A 192.0.2.10, got %+v", recs[0].RR)
+	}
+	if recs[0].TTL != 3600 {
+		t.Errorf("expected $TTL default of 3600, got %d", recs[0].TTL)
+	}
+}
+
+func TestParseZoneGenerate(t *testing.T) {
+	zone, err := parseZone(strings.NewReader(testZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recs, ok := zone.lookup("host2.example.com", TypeA, 1)
+	if !ok || len(recs) != 1 {
+		t.Fatalf("expected $GENERATE to produce host2.example.com, got %+v", recs)
+	}
+	aRecord := recs[0].RR.(*ARecord)
+	if aRecord.IP.String() != "10.0.0.2" {
+		t.Errorf("expected host2.example.com A 10.0.0.2, got %s", aRecord.IP.String())
+	}
+}
+
+func TestZoneResolveFollowsCNAMEAndAddsAuthority(t *testing.T) {
+	zone, err := parseZone(strings.NewReader(testZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	question := Question{domainName: "alias.example.com", questionType: TypeA, questionClass: 1}
+	answers, authority, additional, rcode := zone.Resolve(question)
+
+	if rcode != 0 {
+		t.Fatalf("expected RCODE 0, got %d", rcode)
+	}
+	if len(answers) != 2 {
+		t.Fatalf("expected CNAME + A answers, got %+v", answers)
+	}
+	if answers[0].answerType != TypeCNAME || answers[1].answerType != TypeA {
+		t.Errorf("expected CNAME then A, got types %d, %d", answers[0].answerType, answers[1].answerType)
+	}
+
+	if len(authority) != 1 || authority[0].answerType != TypeNS {
+		t.Fatalf("expected one NS authority record, got %+v", authority)
+	}
+	if len(additional) != 1 || additional[0].answerType != TypeA {
+		t.Fatalf("expected glue A record for ns1, got %+v", additional)
+	}
+}
+
+func TestZoneResolveNXDOMAIN(t *testing.T) {
+	zone, err := parseZone(strings.NewReader(testZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	question := Question{domainName: "missing.example.com", questionType: TypeA, questionClass: 1}
+	answers, authority, _, rcode := zone.Resolve(question)
+
+	if rcode != 3 {
+		t.Errorf("expected NXDOMAIN (RCODE 3), got %d", rcode)
+	}
+	if len(answers) != 0 {
+		t.Errorf("expected no answers, got %+v", answers)
+	}
+	if len(authority) != 1 || authority[0].answerType != TypeSOA {
+		t.Errorf("expected SOA in authority for negative caching, got %+v", authority)
+	}
+}
+
+func TestZoneResolveDanglingCNAMEIsNXDOMAIN(t *testing.T) {
+	zone, err := parseZone(strings.NewReader(testZoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	question := Question{domainName: "dangling.example.com", questionType: TypeA, questionClass: 1}
+	answers, authority, _, rcode := zone.Resolve(question)
+
+	if rcode != 3 {
+		t.Errorf("expected NXDOMAIN (RCODE 3) for a CNAME target that doesn't exist, got %d", rcode)
+	}
+	if len(answers) != 1 || answers[0].answerType != TypeCNAME {
+		t.Errorf("expected the dangling CNAME itself in the answer section, got %+v", answers)
+	}
+	if len(authority) != 1 || authority[0].answerType != TypeSOA {
+		t.Errorf("expected SOA in authority for negative caching, got %+v", authority)
+	}
+}